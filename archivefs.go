@@ -0,0 +1,309 @@
+package cpio
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// archiveEntry is the indexed form of a single Header read
+// from an archive. Directories that are implied by a deeper
+// entry's path, but never appear in the archive themselves,
+// are synthesized with a nil header.
+type archiveEntry struct {
+	name    string // base name
+	header  *Header
+	mode    os.FileMode
+	modtime time.Time
+	offset  int64  // body offset within the ReaderAt (regular files and symlinks)
+	size    int64  // body size within the ReaderAt
+	link    string // resolved symlink target path, set once indexing is complete
+}
+
+func (e *archiveEntry) isDir() bool     { return e.mode&os.ModeDir != 0 }
+func (e *archiveEntry) isSymlink() bool { return e.mode&os.ModeSymlink != 0 }
+
+// archiveFS is the fs.FS returned by NewFS.
+type archiveFS struct {
+	ra      io.ReaderAt
+	entries map[string]*archiveEntry // keyed by cleaned, slash-separated path; root is "."
+	kids    map[string][]string      // directory path -> sorted child base names
+}
+
+// NewFS indexes the headers of a cpio archive and returns a
+// read-only fs.FS view over its contents, in the manner of
+// zip.Reader's fs.FS support. The returned fs.FS additionally
+// implements fs.ReadDirFS, fs.StatFS, and fs.ReadFileFS.
+//
+// Symlinks are resolved against other entries in the archive.
+// Hard links, identified by entries that share a nonzero Ino,
+// share the content of the first entry with that Ino. Any
+// directories implied by an entry's path, but not themselves
+// present in the archive, are synthesized.
+func NewFS(r io.ReaderAt, size int64) (fs.FS, error) {
+	fsys := &archiveFS{
+		ra:      r,
+		entries: make(map[string]*archiveEntry),
+		kids:    make(map[string][]string),
+	}
+	fsys.entries["."] = &archiveEntry{name: ".", mode: os.ModeDir | 0555}
+
+	type inodeSpan struct{ offset, size int64 }
+	byIno := make(map[int]inodeSpan)
+
+	cr := &countingReader{r: io.NewSectionReader(r, 0, size)}
+	rd := NewReader(cr)
+	for {
+		h, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := cleanArchiveName(h.Name)
+		if name == "." {
+			continue
+		}
+		offset, n := cr.pos, int64(h.Size)
+		if h.Ino != 0 {
+			if prev, ok := byIno[h.Ino]; ok {
+				offset, n = prev.offset, prev.size
+			} else {
+				byIno[h.Ino] = inodeSpan{offset, n}
+			}
+		}
+		fsys.link(name, &archiveEntry{
+			name:    path.Base(name),
+			header:  h,
+			mode:    h.Mode,
+			modtime: h.Modtime,
+			offset:  offset,
+			size:    n,
+		})
+	}
+
+	// Resolve symlink targets now that every entry is indexed,
+	// so a link can point at an entry added after it.
+	for name, e := range fsys.entries {
+		if !e.isSymlink() {
+			continue
+		}
+		target := make([]byte, e.size)
+		if _, err := r.ReadAt(target, e.offset); err != nil {
+			return nil, fmt.Errorf("cpio: reading symlink %q: %w", name, err)
+		}
+		if path.IsAbs(string(target)) {
+			e.link = cleanArchiveName(string(target))
+		} else {
+			e.link = path.Clean(path.Join(path.Dir(name), string(target)))
+		}
+	}
+
+	return fsys, nil
+}
+
+// cleanArchiveName turns a Header.Name into a clean,
+// slash-separated fs.FS path: no leading "/" or "./", no ".."
+// escapes, and "." for the archive root.
+func cleanArchiveName(name string) string {
+	c := path.Clean("/" + name)
+	if c == "/" {
+		return "."
+	}
+	return c[1:]
+}
+
+// link registers e at name, synthesizing any parent directories
+// that aren't already indexed and recording e as their child.
+func (f *archiveFS) link(name string, e *archiveEntry) {
+	f.entries[name] = e
+	dir := path.Dir(name)
+	for {
+		if _, ok := f.entries[dir]; !ok {
+			f.entries[dir] = &archiveEntry{name: path.Base(dir), mode: os.ModeDir | 0555}
+		}
+		f.addChild(dir, path.Base(name))
+		if dir == "." {
+			break
+		}
+		name, dir = dir, path.Dir(dir)
+	}
+}
+
+func (f *archiveFS) addChild(dir, base string) {
+	for _, c := range f.kids[dir] {
+		if c == base {
+			return
+		}
+	}
+	f.kids[dir] = append(f.kids[dir], base)
+	sort.Strings(f.kids[dir])
+}
+
+// resolve looks up name, following symlinks (within the
+// archive) up to a bounded depth to avoid infinite loops.
+func (f *archiveFS) resolve(name string) (string, *archiveEntry, error) {
+	for i := 0; ; i++ {
+		if i > 40 {
+			return "", nil, &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("too many levels of symbolic links")}
+		}
+		e, ok := f.entries[name]
+		if !ok {
+			return "", nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if !e.isSymlink() {
+			return name, e, nil
+		}
+		name = e.link
+	}
+}
+
+func (f *archiveFS) info(name string, e *archiveEntry) fs.FileInfo {
+	return archiveFileInfo{name: path.Base(name), e: e}
+}
+
+// Open implements fs.FS.
+func (f *archiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	rname, e, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.isDir() {
+		return &archiveDir{fs: f, name: rname, info: f.info(rname, e)}, nil
+	}
+	return &archiveFile{
+		info:    f.info(rname, e),
+		section: io.NewSectionReader(f.ra, e.offset, e.size),
+	}, nil
+}
+
+// Stat implements fs.StatFS.
+func (f *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	rname, e, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.info(rname, e), nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (f *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	rname, e, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	kids := f.kids[rname]
+	out := make([]fs.DirEntry, len(kids))
+	for i, base := range kids {
+		child := rname + "/" + base
+		if rname == "." {
+			child = base
+		}
+		out[i] = fs.FileInfoToDirEntry(f.info(child, f.entries[child]))
+	}
+	return out, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (f *archiveFS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// archiveFileInfo implements fs.FileInfo; ReadDir wraps it
+// with fs.FileInfoToDirEntry to produce fs.DirEntry values.
+type archiveFileInfo struct {
+	name string
+	e    *archiveEntry
+}
+
+func (i archiveFileInfo) Name() string       { return i.name }
+func (i archiveFileInfo) Size() int64        { return i.e.size }
+func (i archiveFileInfo) Mode() os.FileMode  { return i.e.mode }
+func (i archiveFileInfo) ModTime() time.Time { return i.e.modtime }
+func (i archiveFileInfo) IsDir() bool        { return i.e.isDir() }
+func (i archiveFileInfo) Sys() interface{}   { return i.e.header }
+
+// archiveFile implements fs.File for a regular file or symlink.
+type archiveFile struct {
+	info    fs.FileInfo
+	section *io.SectionReader
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *archiveFile) Read(p []byte) (int, error) { return f.section.Read(p) }
+func (f *archiveFile) Close() error               { return nil }
+
+// archiveDir implements fs.ReadDirFile for a directory.
+type archiveDir struct {
+	fs     *archiveFS
+	name   string
+	info   fs.FileInfo
+	offset int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *archiveDir) Close() error               { return nil }
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fmt.Errorf("is a directory")}
+}
+
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	all, err := d.fs.ReadDir(d.name)
+	if err != nil {
+		return nil, err
+	}
+	if d.offset >= len(all) {
+		if n <= 0 {
+			return nil, nil
+		}
+		return nil, io.EOF
+	}
+	if n <= 0 {
+		out := all[d.offset:]
+		d.offset = len(all)
+		return out, nil
+	}
+	end := d.offset + n
+	if end > len(all) {
+		end = len(all)
+	}
+	out := all[d.offset:end]
+	d.offset = end
+	return out, nil
+}
+
+// countingReader wraps an io.Reader and tracks the total
+// number of bytes read, so NewFS can record each header's
+// body offset as it scans the archive sequentially.
+type countingReader struct {
+	r   io.Reader
+	pos int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.pos += int64(n)
+	return n, err
+}
@@ -0,0 +1,93 @@
+package cpio
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func buildArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	files := []struct {
+		h    Header
+		body string
+	}{
+		{Header{Name: "dir", Mode: os.ModeDir | 0755, Nlink: 1}, ""},
+		{Header{Name: "dir/a.txt", Mode: 0644, Nlink: 1, Ino: 1, Size: 5}, "hello"},
+		// size 0 and an empty body, matching what
+		// Writer.SetHardlinkDetect actually emits for a
+		// duplicate Ino: the read must come from a.txt's
+		// span, not hard.txt's own (absent) bytes.
+		{Header{Name: "dir/hard.txt", Mode: 0644, Nlink: 2, Ino: 1, Size: 0}, ""},
+		{Header{Name: "link.txt", Mode: os.ModeSymlink | 0777, Nlink: 1}, "dir/a.txt"},
+		{Header{Name: "target.txt", Mode: 0644, Nlink: 1}, "world"},
+		{Header{Name: "dir/abslink", Mode: os.ModeSymlink | 0777, Nlink: 1}, "/target.txt"},
+	}
+	for _, f := range files {
+		h := f.h
+		h.Size = uint32(len(f.body))
+		if err := w.WriteHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveFS(t *testing.T) {
+	data := buildArchive(t)
+	fsys, err := NewFS(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "dir", "dir/a.txt", "dir/hard.txt", "link.txt", "target.txt", "dir/abslink"); err != nil {
+		t.Fatal(err)
+	}
+
+	// hard link shares content with the entry it points at
+	got, err := fs.ReadFile(fsys, "dir/hard.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("hard link content = %q, want %q", got, "hello")
+	}
+
+	// symlink resolves to the file it points at
+	got, err = fs.ReadFile(fsys, "link.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("symlink content = %q, want %q", got, "hello")
+	}
+
+	// an absolute symlink target resolves against the archive
+	// root, not the symlink's own directory
+	got, err = fs.ReadFile(fsys, "dir/abslink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "world" {
+		t.Errorf("absolute symlink content = %q, want %q", got, "world")
+	}
+
+	entries, err := fs.ReadDir(fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	listcmp(t, names, []string{"a.txt", "hard.txt", "abslink"})
+}
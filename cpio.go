@@ -3,7 +3,9 @@
 // The cpio archive format is an (ancient) archive format
 // similar to tar. There are a couple header formats for cpio,
 // but the cpio tool that ships with busybox only implements
-// the 'newc' format, so that is what this package implements.
+// the 'newc' and 'crc' formats, so those are what this package
+// implements. The two formats are identical except that 'crc'
+// additionally carries a checksum of each file's contents.
 //
 // The API for this package closely mirrors the archive/tar
 // package in the standard library.
@@ -20,6 +22,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"time"
 )
@@ -29,6 +32,22 @@ var (
 	ErrBadMagic     = errors.New("cpio: bad header magic")
 	ErrClosed       = errors.New("cpio: writer already closed")
 	ErrWriteTooLong = errors.New("cpio: write too long")
+	ErrChecksum     = errors.New("cpio: checksum mismatch")
+)
+
+// Format identifies the on-disk encoding of a cpio header.
+type Format int
+
+const (
+	// FormatNewc is the 'newc' (070701) format. It is the
+	// default format, and does not carry a checksum of the
+	// file's contents.
+	FormatNewc Format = iota
+	// FormatCRC is the 'crc' (070702) format. It is identical
+	// to FormatNewc except that the header's checksum field
+	// is set to the sum of the file's data bytes, and readers
+	// are expected to verify it.
+	FormatCRC
 )
 
 // Header represents a cpio header.
@@ -52,12 +71,24 @@ type Header struct {
 	Uid, Gid             int
 	Nlink                int
 	Modtime              time.Time
-	sum                  uint32 // likely to be 0
+	Format               Format // newc or crc; zero value is FormatNewc
+	sum                  uint32 // crc-format checksum; 0 for newc
+}
+
+// IsHardlink reports whether h is a hard-link duplicate entry:
+// a zero-size, non-directory file with more than one link. Its
+// contents are the same as a previously-seen entry with the
+// same (Devmajor, Devminor, Ino); see (*Reader).Links.
+func (h *Header) IsHardlink() bool {
+	return h.Nlink > 1 && h.Size == 0 && !h.Mode.IsDir()
 }
 
 const newcSize = (13 * 8) + 6
 
-var newcMagic = []byte{'0', '7', '0', '7', '0', '1'}
+var (
+	newcMagic = []byte{'0', '7', '0', '7', '0', '1'}
+	crcMagic  = []byte{'0', '7', '0', '7', '0', '2'}
+)
 
 func be(b []byte) int {
 	return int(binary.BigEndian.Uint32(b))
@@ -76,14 +107,19 @@ func (h *Header) parse(r io.Reader) error {
 	}
 
 	_ = buf[:newcSize]
-	if !bytes.Equal(buf[:6], newcMagic) {
+	switch {
+	case bytes.Equal(buf[:6], newcMagic):
+		h.Format = FormatNewc
+	case bytes.Equal(buf[:6], crcMagic):
+		h.Format = FormatCRC
+	default:
 		return ErrBadMagic
 	}
 
 	var bin [(newcSize - 6) / 2]byte
 	_, err = hex.Decode(bin[:], buf[6:])
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %s", ErrBadMagic, err)
 	}
 
 	h.Ino = be(bin[0:])
@@ -100,15 +136,22 @@ func (h *Header) parse(r io.Reader) error {
 	namesize := be(bin[44:])
 	h.sum = uint32(be(bin[48:]))
 
-	// XXX: this is probably a lot more than PATH_MAX
-	if namesize > 1024 {
-		return fmt.Errorf("cpio: file name length %d too large", namesize)
+	// a name is at least a NULL byte, and its length is capped
+	// at 1024 to bound the name allocation below;
+	// XXX: 1024 is probably a lot more than PATH_MAX anyway
+	if namesize <= 0 || namesize > 1024 {
+		return fmt.Errorf("cpio: invalid file name length %d", namesize)
+	}
+	// padding the size up to a 4-byte boundary must not
+	// overflow uint32, or curpad math downstream goes haywire
+	if h.Size > math.MaxUint32-3 {
+		return fmt.Errorf("cpio: file size %d too large to pad", h.Size)
 	}
 
 	// need a NULL byte at the end, and then
 	// 4-byte alignment of the rest;
 	// the header itself is 2-byte misaligned...
-	namebuf := make([]byte, (namesize+3+2)&^3 - 2)
+	namebuf := make([]byte, (namesize+3+2)&^3-2)
 	_, err = io.ReadFull(r, namebuf)
 	if err != nil {
 		return err
@@ -133,8 +176,13 @@ func (h *Header) write(w io.Writer) error {
 	itobe(binbuf[44:], len(h.Name)+1) // null-terminated
 	itobe(binbuf[48:], int(h.sum))
 
+	magic := newcMagic
+	if h.Format == FormatCRC {
+		magic = crcMagic
+	}
+
 	buf := make([]byte, (newcSize+len(h.Name)+1+3)&^3)
-	copy(buf, newcMagic)
+	copy(buf, magic)
 	hex.Encode(buf[len(newcMagic):], binbuf[:])
 	copy(buf[newcSize:], h.Name)
 
@@ -147,6 +195,11 @@ type Reader struct {
 	r       io.Reader
 	curfile io.LimitedReader
 	curpad  int
+	curname string
+	crc     bool   // current file's header is crc-format
+	wantsum uint32 // checksum from the current file's header
+	gotsum  uint32 // checksum accumulated from bytes read so far
+	links   map[uint64][]string
 }
 
 // NewReader constructs a new Reader.
@@ -154,13 +207,44 @@ func NewReader(r io.Reader) *Reader {
 	return &Reader{r: r, curfile: io.LimitedReader{R: r}}
 }
 
+// Links returns, for every Ino with more than one link seen so
+// far, the names of all entries sharing that Ino. The first
+// name recorded for a given Ino is the entry that carries the
+// actual file contents; the rest are zero-size hard-link
+// duplicates (see Header.IsHardlink) whose contents are the
+// same as the first. Since Links only reflects entries already
+// returned by Next, it should be called after the archive has
+// been fully read.
+func (r *Reader) Links() map[uint64][]string {
+	return r.links
+}
+
 // Read reads the contents of the current file.
 // Read will return io.EOF when the current file's
 // contents are exhausted, at which point the caller
 // may call (*Reader).Next() to advance to the next
 // file in the archive.
+//
+// If the current file's header is in crc format, Read
+// returns ErrChecksum once the file's contents have been
+// fully consumed and the accumulated checksum does not
+// match the value recorded in the header.
 func (r *Reader) Read(p []byte) (int, error) {
-	return r.curfile.Read(p)
+	n, err := r.curfile.Read(p)
+	r.addsum(p[:n])
+	if err == io.EOF && r.crc && r.gotsum != r.wantsum {
+		return n, fmt.Errorf("%w: file %q", ErrChecksum, r.curname)
+	}
+	return n, err
+}
+
+func (r *Reader) addsum(p []byte) {
+	if !r.crc {
+		return
+	}
+	for _, b := range p {
+		r.gotsum += uint32(b)
+	}
 }
 
 const trailername = "TRAILER!!!"
@@ -168,10 +252,22 @@ const trailername = "TRAILER!!!"
 // Next advances to the next file in the archive.
 // If the current file has not been read completely,
 // its contents are discarded before advancing to the
-// next file.
+// next file; if the current file's header is in crc
+// format, the discarded bytes are still checked against
+// the recorded checksum, and ErrChecksum is returned on
+// mismatch before the next header is parsed.
 func (r *Reader) Next() (*Header, error) {
-	if leftover := r.curfile.N + int64(r.curpad); leftover > 0 {
-		_, err := io.CopyN(ioutil.Discard, r.r, leftover)
+	if r.curfile.N > 0 {
+		_, err := io.Copy(sumDiscard{r}, &r.curfile)
+		if err != nil {
+			return nil, err
+		}
+		if r.crc && r.gotsum != r.wantsum {
+			return nil, fmt.Errorf("%w: file %q", ErrChecksum, r.curname)
+		}
+	}
+	if r.curpad > 0 {
+		_, err := io.CopyN(ioutil.Discard, r.r, int64(r.curpad))
 		if err != nil {
 			return nil, err
 		}
@@ -188,16 +284,50 @@ func (r *Reader) Next() (*Header, error) {
 	}
 	r.curfile.N = int64(h.Size)
 	r.curpad = int(((h.Size + 3) &^ 3) - h.Size)
+	r.curname = h.Name
+	r.crc = h.Format == FormatCRC
+	r.wantsum = h.sum
+	r.gotsum = 0
+	if h.Nlink > 1 {
+		if r.links == nil {
+			r.links = make(map[uint64][]string)
+		}
+		ino := uint64(h.Ino)
+		r.links[ino] = append(r.links[ino], h.Name)
+	}
 	return h, nil
 }
 
+// sumDiscard is an io.Writer that feeds bytes through
+// (*Reader).addsum and otherwise discards them, used to
+// keep the checksum accurate when a file's contents are
+// skipped rather than read.
+type sumDiscard struct{ r *Reader }
+
+func (s sumDiscard) Write(p []byte) (int, error) {
+	s.r.addsum(p)
+	return len(p), nil
+}
+
 // Writer writes cpio archives.
 type Writer struct {
-	w       io.Writer
-	fsize   int64
-	needpad int
-	zpad    [4]byte
-	closed  bool
+	w        io.Writer
+	fsize    int64
+	needpad  int
+	zpad     [4]byte
+	closed   bool
+	pending  *Header         // header awaiting a checksum patch, for crc format
+	body     bytes.Buffer    // buffered body for pending, since crc needs the sum before the header is written
+	sum      uint32          // sum accumulated for pending's body
+	hardlink bool            // hard-link detection enabled; see SetHardlinkDetect
+	seen     map[devino]bool // inodes already written, for hard-link detection
+	skipbody bool            // current entry is a hard-link duplicate; discard its body
+}
+
+// devino identifies a file uniquely by the tuple cpio uses to
+// mean "same inode": its device and inode numbers.
+type devino struct {
+	devmajor, devminor, ino int
 }
 
 // NewWriter constructs a new Writer.
@@ -205,6 +335,22 @@ func NewWriter(w io.Writer) *Writer {
 	return &Writer{w: w}
 }
 
+// SetHardlinkDetect enables or disables hard-link detection.
+// When enabled, WriteHeader tracks the (Devmajor, Devminor, Ino)
+// of every header it is given, and when that tuple repeats, it
+// writes a header with Size == 0 instead of the one passed in
+// and discards any bytes subsequently passed to Write, matching
+// the space-efficient archives produced by GNU cpio and
+// busybox. The first entry for a given inode is left untouched
+// and must carry the real file contents.
+//
+// Headers with Ino == 0 are never treated as hard links, since
+// that is the conventional way to indicate "no inode
+// information available".
+func (w *Writer) SetHardlinkDetect(enable bool) {
+	w.hardlink = enable
+}
+
 // Flush flushes any padding necessary at the end of the current file.
 // An error is returned if the Writer is closed, or if there are bytes
 // left to be written for the current file (as indicated in the Size
@@ -216,6 +362,18 @@ func (w *Writer) Flush() error {
 	if w.fsize > 0 {
 		return fmt.Errorf("cpio: writer flushed with %d bytes remaining to write", w.fsize)
 	}
+	if w.pending != nil {
+		w.pending.sum = w.sum
+		if err := w.pending.write(w.w); err != nil {
+			return err
+		}
+		if _, err := w.w.Write(w.body.Bytes()); err != nil {
+			return err
+		}
+		w.pending = nil
+		w.body.Reset()
+		w.sum = 0
+	}
 	if w.needpad > 0 {
 		i, err := w.w.Write(w.zpad[:w.needpad])
 		w.needpad -= i
@@ -234,7 +392,33 @@ func (w *Writer) WriteHeader(h *Header) error {
 	if err := w.Flush(); err != nil {
 		return err
 	}
-	if err := h.write(w.w); err != nil {
+	w.skipbody = false
+	if w.hardlink && h.Ino != 0 {
+		key := devino{h.Devmajor, h.Devminor, h.Ino}
+		if w.seen == nil {
+			w.seen = make(map[devino]bool)
+		}
+		if w.seen[key] {
+			dup := *h
+			dup.Size = 0
+			if err := dup.write(w.w); err != nil {
+				return err
+			}
+			w.fsize = int64(h.Size)
+			w.needpad = 0
+			w.skipbody = true
+			return nil
+		}
+		w.seen[key] = true
+	}
+	if h.Format == FormatCRC {
+		// The crc format's checksum covers the body, which
+		// hasn't been written yet, so buffer the body and
+		// defer writing the header until the sum is known
+		// (see Flush).
+		hdr := *h
+		w.pending = &hdr
+	} else if err := h.write(w.w); err != nil {
 		return err
 	}
 	w.fsize = int64(h.Size)
@@ -255,6 +439,18 @@ func (w *Writer) Write(b []byte) (int, error) {
 	if int64(len(b)) > w.fsize {
 		return 0, ErrWriteTooLong
 	}
+	if w.skipbody {
+		w.fsize -= int64(len(b))
+		return len(b), nil
+	}
+	if w.pending != nil {
+		for _, c := range b {
+			w.sum += uint32(c)
+		}
+		i, _ := w.body.Write(b)
+		w.fsize -= int64(i)
+		return i, nil
+	}
 	i, err := w.w.Write(b)
 	w.fsize -= int64(i)
 	return i, err
@@ -0,0 +1,128 @@
+package cpio
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+)
+
+// entrySep marks the boundary between entries in a Digest's
+// input, so that e.g. a file literally containing the bytes
+// "name=x" can't be confused with a canonicalized header.
+var entrySep = []byte("\x00cpio.Digest\x00")
+
+// Digest computes a deterministic hash of a cpio archive's
+// logical contents: each entry's canonicalized header fields
+// plus its body, independent of encoding-level noise such as
+// hex-digit casing, inode renumbering, or modtime jitter. It
+// is the cpio analogue of docker's tarsum.
+//
+// Digest wraps an arbitrary hash.Hash. WriteHeader feeds it a
+// new entry's canonicalized header, and Digest is itself an
+// io.Writer for streaming that entry's body, so it can be
+// driven from inside a Reader loop:
+//
+//	d := NewDigest(sha256.New())
+//	for {
+//		h, err := r.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		d.WriteHeader(h)
+//		io.Copy(d, r)
+//	}
+//	sum := d.Sum(nil)
+//
+// Sum provides this loop as a convenience for the common case
+// of summing a whole archive at once.
+type Digest struct {
+	h     hash.Hash
+	mtime bool // include Modtime in the canonicalized header
+}
+
+// NewDigest constructs a Digest that hashes into h.
+func NewDigest(h hash.Hash) *Digest {
+	return &Digest{h: h}
+}
+
+// IncludeMtime controls whether each entry's Modtime is part of
+// its canonicalized header. It is excluded by default, since
+// modtime is often the only thing that differs between
+// otherwise content-identical archives.
+func (d *Digest) IncludeMtime(include bool) {
+	d.mtime = include
+}
+
+// WriteHeader feeds h's canonicalized header into the digest,
+// ready for the entry's body to be written via Write.
+func (d *Digest) WriteHeader(h *Header) error {
+	if _, err := d.h.Write(entrySep); err != nil {
+		return err
+	}
+	for _, line := range canonicalLines(h, d.mtime) {
+		if _, err := io.WriteString(d.h, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write feeds body bytes, for the entry most recently started
+// with WriteHeader, into the digest.
+func (d *Digest) Write(p []byte) (int, error) {
+	return d.h.Write(p)
+}
+
+// Sum appends the current hash to b and returns the resulting
+// slice, in the manner of hash.Hash.Sum.
+func (d *Digest) Sum(b []byte) []byte {
+	return d.h.Sum(b)
+}
+
+// canonicalLines returns h's digest-relevant fields as sorted
+// "key=value\n" lines, so the result doesn't depend on struct
+// field order.
+func canonicalLines(h *Header, includeMtime bool) []string {
+	lines := []string{
+		fmt.Sprintf("devmajor=%d\n", h.Devmajor),
+		fmt.Sprintf("devminor=%d\n", h.Devminor),
+		fmt.Sprintf("gid=%d\n", h.Gid),
+		fmt.Sprintf("mode=%o\n", unixmode(h.Mode)),
+		fmt.Sprintf("name=%s\n", h.Name),
+		fmt.Sprintf("nlink=%d\n", h.Nlink),
+		fmt.Sprintf("rdevmajor=%d\n", h.Rdevmajor),
+		fmt.Sprintf("rdevminor=%d\n", h.Rdevminor),
+		fmt.Sprintf("size=%d\n", h.Size),
+		fmt.Sprintf("uid=%d\n", h.Uid),
+	}
+	if includeMtime {
+		lines = append(lines, fmt.Sprintf("mtime=%d\n", h.Modtime.Unix()))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// Sum consumes a full archive from r and returns the SHA-256
+// Digest of its logical contents.
+func Sum(r io.Reader) ([]byte, error) {
+	d := NewDigest(sha256.New())
+	cr := NewReader(r)
+	for {
+		h, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := d.WriteHeader(h); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(d, cr); err != nil {
+			return nil, err
+		}
+	}
+	return d.Sum(nil), nil
+}
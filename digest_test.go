@@ -0,0 +1,70 @@
+package cpio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func archiveWith(t *testing.T, modtime int64) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	h := &Header{Name: "a.txt", Size: 5, Mode: 0644, Nlink: 1, Modtime: time.Unix(modtime, 0)}
+	if err := w.WriteHeader(h); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDigestIgnoresModtimeByDefault(t *testing.T) {
+	a := archiveWith(t, 1000)
+	b := archiveWith(t, 2000)
+
+	sumA, err := Sum(bytes.NewReader(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sumB, err := Sum(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sumA, sumB) {
+		t.Error("Sum should be independent of modtime by default")
+	}
+}
+
+func TestDigestContentSensitive(t *testing.T) {
+	a := archiveWith(t, 1000)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	h := &Header{Name: "a.txt", Size: 5, Mode: 0644, Nlink: 1}
+	if err := w.WriteHeader(h); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sumA, err := Sum(bytes.NewReader(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sumB, err := Sum(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(sumA, sumB) {
+		t.Error("Sum should differ when file contents differ")
+	}
+}
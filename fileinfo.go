@@ -0,0 +1,50 @@
+package cpio
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// sysStat, when non-nil, populates the platform-specific
+// fields of a Header (Uid, Gid, Ino, Nlink, Devmajor/minor,
+// and Rdevmajor/minor) from a FileInfo's Sys() value. It is
+// set by an init function in stat_unix.go on platforms where
+// that information is available.
+var sysStat func(fi os.FileInfo, h *Header) error
+
+// FileInfoHeader returns a Header built from fi, in the manner
+// of tar.FileInfoHeader. If fi describes a symlink, link should
+// be the target of that link; its length is stored as Size, since (per
+// the Header docs) symlink targets are written as the entry's
+// body rather than as a separate header field.
+//
+// On Unix platforms, FileInfoHeader also populates Uid, Gid,
+// Ino, Nlink, Devmajor/minor, and Rdevmajor/minor from fi.Sys(),
+// when it is a *syscall.Stat_t.
+func FileInfoHeader(fi os.FileInfo, link string) (*Header, error) {
+	if fi == nil {
+		return nil, fmt.Errorf("cpio: nil FileInfo")
+	}
+	h := &Header{
+		Name:    fi.Name(),
+		Mode:    fi.Mode(),
+		Modtime: fi.ModTime(),
+		Nlink:   1,
+	}
+	switch {
+	case fi.Mode().IsRegular():
+		if fi.Size() > math.MaxUint32 {
+			return nil, fmt.Errorf("cpio: file %q is too large for the newc/crc format (%d bytes)", fi.Name(), fi.Size())
+		}
+		h.Size = uint32(fi.Size())
+	case fi.Mode()&os.ModeSymlink != 0:
+		h.Size = uint32(len(link))
+	}
+	if sysStat != nil {
+		if err := sysStat(fi, h); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,76 @@
+//go:build unix
+
+package cpio
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileInfoHeader(t *testing.T) {
+	dir := t.TempDir()
+	fp := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(fp, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := FileInfoHeader(fi, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Name != "a.txt" {
+		t.Errorf("Name = %q, want %q", h.Name, "a.txt")
+	}
+	if h.Size != 5 {
+		t.Errorf("Size = %d, want 5", h.Size)
+	}
+	if h.Uid != os.Getuid() {
+		t.Errorf("Uid = %d, want %d", h.Uid, os.Getuid())
+	}
+	if h.Gid != os.Getgid() {
+		t.Errorf("Gid = %d, want %d", h.Gid, os.Getgid())
+	}
+	if h.Ino == 0 {
+		t.Error("Ino wasn't populated from *syscall.Stat_t")
+	}
+	if h.Nlink != 1 {
+		t.Errorf("Nlink = %d, want 1", h.Nlink)
+	}
+}
+
+func TestFileInfoHeaderNil(t *testing.T) {
+	if _, err := FileInfoHeader(nil, ""); err == nil {
+		t.Fatal("expected an error for a nil FileInfo")
+	}
+}
+
+// fakeFileInfo is a minimal os.FileInfo whose Size can be set
+// past the newc/crc format's uint32 limit, and whose Sys is
+// always nil so sysStat leaves the Header's platform fields
+// untouched.
+type fakeFileInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (fi fakeFileInfo) Name() string       { return fi.name }
+func (fi fakeFileInfo) Size() int64        { return fi.size }
+func (fi fakeFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fakeFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fakeFileInfo) Sys() interface{}   { return nil }
+
+func TestFileInfoHeaderTooLarge(t *testing.T) {
+	fi := fakeFileInfo{name: "huge.txt", size: math.MaxUint32 + 1, mode: 0644}
+	if _, err := FileInfoHeader(fi, ""); err == nil {
+		t.Fatal("expected an error for a file too large for the newc/crc format")
+	}
+}
@@ -0,0 +1,45 @@
+package cpio
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// fuzzSeed builds a small, self-contained archive to seed the
+// fuzzer with, in case testdata/dir.cpio isn't present.
+func fuzzSeed() []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteHeader(&Header{Name: "a", Size: 5, Mode: 0644, Nlink: 1})
+	w.Write([]byte("hello"))
+	w.WriteHeader(&Header{Name: "dir", Mode: os.ModeDir | 0755, Nlink: 1})
+	w.Close()
+	return buf.Bytes()
+}
+
+func FuzzReader(f *testing.F) {
+	if data, err := os.ReadFile("testdata/dir.cpio"); err == nil {
+		f.Add(data)
+	}
+	f.Add(fuzzSeed())
+	f.Add([]byte{})
+	f.Add(newcMagic)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := NewReader(bytes.NewReader(data))
+		// a real archive can't have more entries than it has
+		// bytes; this just keeps a pathological input from
+		// spinning Next() forever.
+		for i := 0; i < len(data)+1; i++ {
+			_, err := r.Next()
+			if err != nil {
+				return
+			}
+			if _, err := ioutil.ReadAll(r); err != nil {
+				return
+			}
+		}
+	})
+}
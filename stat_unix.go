@@ -0,0 +1,39 @@
+//go:build unix
+
+package cpio
+
+import (
+	"os"
+	"syscall"
+)
+
+func init() {
+	sysStat = statUnix
+}
+
+// major and minor follow the glibc gnu_dev_major/gnu_dev_minor
+// encoding, which matches the Devmajor/Devminor split already
+// used by Header on Linux.
+func major(dev uint64) int {
+	return int((dev>>8)&0xfff | (dev>>32)&0xfffff000)
+}
+
+func minor(dev uint64) int {
+	return int(dev&0xff | (dev>>12)&0xffffff00)
+}
+
+func statUnix(fi os.FileInfo, h *Header) error {
+	sys, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	h.Uid = int(sys.Uid)
+	h.Gid = int(sys.Gid)
+	h.Ino = int(sys.Ino)
+	h.Nlink = int(sys.Nlink)
+	h.Devmajor = major(uint64(sys.Dev))
+	h.Devminor = minor(uint64(sys.Dev))
+	h.Rdevmajor = major(uint64(sys.Rdev))
+	h.Rdevminor = minor(uint64(sys.Rdev))
+	return nil
+}
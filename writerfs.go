@@ -0,0 +1,71 @@
+package cpio
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// readLinkFS is the subset of the (eventual) io/fs.ReadLinkFS
+// interface that AddFS needs to recover a symlink's target,
+// since fs.FS itself has no notion of symlinks.
+type readLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+}
+
+// AddFS walks fsys and writes every regular file, directory,
+// and symlink it contains into the archive, in the same
+// deterministic (lexical) order used by fs.WalkDir.
+//
+// Symlinks can only be added if fsys implements
+// ReadLink(name string) (string, error); otherwise AddFS
+// returns an error when it encounters one.
+func (w *Writer) AddFS(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			rl, ok := fsys.(readLinkFS)
+			if !ok {
+				return fmt.Errorf("cpio: %s is a symlink, but %T cannot read link targets", name, fsys)
+			}
+			if link, err = rl.ReadLink(name); err != nil {
+				return err
+			}
+		}
+		h, err := FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		h.Name = name
+		if err := w.WriteHeader(h); err != nil {
+			return err
+		}
+		switch {
+		case info.Mode().IsRegular():
+			f, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			_, err = io.WriteString(w, link)
+		}
+		return err
+	})
+}
@@ -0,0 +1,130 @@
+package cpio
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAddFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":     &fstest.MapFile{Data: []byte("hello"), Mode: 0644},
+		"dir/b.txt": &fstest.MapFile{Data: []byte("world"), Mode: 0644},
+		"dir":       &fstest.MapFile{Mode: os.ModeDir | 0755},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.AddFS(fsys); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	var names []string
+	for {
+		h, err := r.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+		want, ok := fsys[h.Name]
+		if !ok {
+			t.Fatalf("unexpected entry %q", h.Name)
+		}
+		if !bytes.Equal(body, want.Data) {
+			t.Errorf("%s: got %q, want %q", h.Name, body, want.Data)
+		}
+	}
+	listcmp(t, names, []string{"a.txt", "dir", "dir/b.txt"})
+}
+
+// symlinkMapFS adds ReadLink support on top of fstest.MapFS, so
+// AddFS can resolve the symlinks in the embedded MapFS.
+type symlinkMapFS struct {
+	fstest.MapFS
+	links map[string]string
+}
+
+func (f symlinkMapFS) ReadLink(name string) (string, error) {
+	target, ok := f.links[name]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	return target, nil
+}
+
+func TestAddFSSymlink(t *testing.T) {
+	fsys := symlinkMapFS{
+		MapFS: fstest.MapFS{
+			"a.txt": &fstest.MapFile{Data: []byte("hello"), Mode: 0644},
+			"link":  &fstest.MapFile{Mode: os.ModeSymlink | 0777},
+		},
+		links: map[string]string{"link": "a.txt"},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.AddFS(fsys); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	var found bool
+	for {
+		h, err := r.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Name != "link" {
+			continue
+		}
+		found = true
+		if h.Mode&os.ModeSymlink == 0 {
+			t.Errorf("link: Mode = %v, want ModeSymlink set", h.Mode)
+		}
+		if string(body) != "a.txt" {
+			t.Errorf("link body = %q, want %q", body, "a.txt")
+		}
+	}
+	if !found {
+		t.Fatal("symlink entry was not written")
+	}
+}
+
+func TestAddFSSymlinkUnsupported(t *testing.T) {
+	// wrapping the MapFS in a plain fs.FS hides any ReadLink
+	// method that fstest.MapFS itself may have
+	fsys := struct{ fs.FS }{fstest.MapFS{
+		"link": &fstest.MapFile{Mode: os.ModeSymlink | 0777},
+	}}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.AddFS(fsys); err == nil {
+		t.Fatal("expected an error for a symlink in an fs.FS that doesn't support ReadLink")
+	}
+}